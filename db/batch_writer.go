@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// BatchWriterConfig controls how BatchWriter groups readings into BatchWriteItem calls
+type BatchWriterConfig struct {
+	// BatchSize is the number of readings coalesced into a single BatchWriteItem call. It's
+	// capped at maxBatchWriteItems regardless of what's configured here.
+	BatchSize int
+
+	// FlushInterval is the longest Run will wait for BatchSize readings to accumulate before
+	// writing a partial batch
+	FlushInterval time.Duration
+
+	// MaxInFlight bounds how many BatchWriteItem calls (and their follow-on hourly rollups) Run
+	// allows to be in progress at once
+	MaxInFlight int
+}
+
+// BatchWriterConfigFromEnv reads batch size, flush interval, and max in-flight batches from the
+// environment, falling back to defaults tuned for SQS's 10-message-per-receive batches
+func BatchWriterConfigFromEnv() BatchWriterConfig {
+	cfg := BatchWriterConfig{BatchSize: 25, FlushInterval: 5 * time.Second, MaxInFlight: 4}
+	if v, err := parsePositiveInt(os.Getenv("STREAMMARKER_BATCH_SIZE")); err == nil {
+		cfg.BatchSize = v
+	}
+	if v, err := parsePositiveInt(os.Getenv("STREAMMARKER_BATCH_MAX_IN_FLIGHT")); err == nil {
+		cfg.MaxInFlight = v
+	}
+	if v, err := parsePositiveInt(os.Getenv("STREAMMARKER_BATCH_FLUSH_INTERVAL")); err == nil {
+		cfg.FlushInterval = time.Duration(v) * time.Second
+	}
+	return cfg
+}
+
+// BatchResult reports what happened to a single message processed by BatchWriter, so a caller
+// draining an SQS queue knows which messages to delete and which to leave for redelivery
+type BatchResult struct {
+	Message *SensorReadingQueueMessage
+	Err     error
+}
+
+// BatchWriter coalesces many SensorReadingQueueMessages into DynamoDB BatchWriteItem calls instead
+// of one PutItem per message, trading a little latency per message for much higher write
+// throughput. WriteSensorReading on dynamoStore remains the right choice for a single reading;
+// BatchWriter is for pipelines that can accumulate several readings before they must be durable.
+type BatchWriter struct {
+	store *dynamoStore
+	cfg   BatchWriterConfig
+	sem   chan struct{}
+}
+
+// NewBatchWriter builds a BatchWriter backed by dynamoDBService. cfg.BatchSize is clamped to
+// maxBatchWriteItems, the most DynamoDB accepts in one BatchWriteItem call.
+func NewBatchWriter(dynamoDBService DynamoDBAPI, cfg BatchWriterConfig) *BatchWriter {
+	if cfg.BatchSize <= 0 || cfg.BatchSize > maxBatchWriteItems {
+		cfg.BatchSize = maxBatchWriteItems
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+
+	return &BatchWriter{
+		store: &dynamoStore{repo: newRepository(dynamoDBService)},
+		cfg:   cfg,
+		sem:   make(chan struct{}, cfg.MaxInFlight),
+	}
+}
+
+// Run drains messages from in, coalescing them into batches of up to cfg.BatchSize and flushing
+// whenever a batch fills and on every tick of a cfg.FlushInterval ticker (the ticker is not reset
+// when a full batch is flushed).
+// Every message's outcome is sent to out, in no particular order, once its batch has been written.
+// Run blocks until in is closed (and every in-flight batch has been flushed) or ctx is cancelled.
+func (b *BatchWriter) Run(ctx context.Context, in <-chan *SensorReadingQueueMessage, out chan<- BatchResult) {
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var pending []*SensorReadingQueueMessage
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		batch := pending
+		pending = nil
+
+		b.sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-b.sem }()
+			for _, result := range b.WriteBatch(ctx, batch) {
+				out <- result
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case m, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, m)
+			if len(pending) >= b.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// WriteBatch applies the same validation and sample-frequency gating WriteSensorReading does to
+// each message, coalesces the raw readings that pass into as few BatchWriteItem calls as
+// cfg.BatchSize allows, then folds every successfully-written reading into its hourly min/max
+// rollup. It returns one BatchResult per message in messages, in the same order.
+func (b *BatchWriter) WriteBatch(ctx context.Context, messages []*SensorReadingQueueMessage) []BatchResult {
+	results := make([]BatchResult, len(messages))
+
+	type prepared struct {
+		index            int
+		sensor           *Sensor
+		readingTimestamp *time.Time
+		item             map[string]types.AttributeValue
+	}
+	var toWrite []prepared
+
+	// batchHighWater tracks the latest reading timestamp accepted so far in this batch, per
+	// sensor. prepareReading only gates against last_reading_timestamp as it stood in DynamoDB
+	// before this batch started, so two readings for the same sensor landing in the same batch
+	// (SQS doesn't guarantee ordering) would otherwise never be gated against each other.
+	batchHighWater := make(map[string]int64)
+
+	for i, m := range messages {
+		results[i] = BatchResult{Message: m}
+
+		sensor, readingTimestamp, err := b.store.prepareReading(ctx, m)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if sensor == nil {
+			// sample frequency gate says this reading should be silently dropped - not a failure
+			continue
+		}
+
+		if highWater, ok := batchHighWater[sensor.ID]; ok && highWater > sensor.LastReadingTimestamp {
+			gated := *sensor
+			gated.LastReadingTimestamp = highWater
+			if !shouldEvaluateSensorReading(readingTimestamp, &gated) {
+				continue
+			}
+		}
+		if ts := readingTimestamp.Unix(); ts > batchHighWater[sensor.ID] {
+			batchHighWater[sensor.ID] = ts
+		}
+
+		item, err := b.store.repo.readingItem(m, sensor)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		toWrite = append(toWrite, prepared{index: i, sensor: sensor, readingTimestamp: readingTimestamp, item: item})
+	}
+
+	for start := 0; start < len(toWrite); start += b.cfg.BatchSize {
+		end := start + b.cfg.BatchSize
+		if end > len(toWrite) {
+			end = len(toWrite)
+		}
+		chunk := toWrite[start:end]
+
+		items := make([]map[string]types.AttributeValue, len(chunk))
+		for i, p := range chunk {
+			items[i] = p.item
+		}
+
+		if err := b.store.repo.putReadings(ctx, items); err != nil {
+			for _, p := range chunk {
+				results[p.index].Err = err
+			}
+			continue
+		}
+
+		// BatchWriteItem can't also bump last_reading_timestamp the way recordReading's
+		// transaction does for a single write, so it's folded in here as its own UpdateItem per
+		// reading once the batch itself is durable.
+		for _, p := range chunk {
+			m := messages[p.index]
+			if err := b.store.repo.updateLastSeen(ctx, p.sensor, int64(m.ReadingTimestamp)); err != nil {
+				results[p.index].Err = err
+				continue
+			}
+			if err := b.store.RecordHourlyMinMax(ctx, m, p.sensor, p.readingTimestamp); err != nil {
+				results[p.index].Err = err
+			}
+		}
+	}
+
+	return results
+}
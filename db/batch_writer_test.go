@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TestPutReadings_RetriesUnprocessedItems verifies putReadings retries only the items DynamoDB
+// reports as UnprocessedItems, and succeeds once a later attempt clears them.
+func TestPutReadings_RetriesUnprocessedItems(t *testing.T) {
+	calls := 0
+	fake := &fakeDynamoDBAPI{
+		batchWriteItemFn: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			calls++
+			if calls == 1 {
+				unprocessed := in.RequestItems[tableName][:1]
+				return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{tableName: unprocessed}}, nil
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	repo := newRepository(fake)
+	items := []map[string]types.AttributeValue{
+		{"PK": &types.AttributeValueMemberS{Value: "a"}},
+		{"PK": &types.AttributeValueMemberS{Value: "b"}},
+	}
+	if err := repo.putReadings(context.Background(), items); err != nil {
+		t.Fatalf("putReadings returned error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected putReadings to retry the unprocessed item once, got %d calls", calls)
+	}
+	if retried := fake.batchWriteItemInputs[1].RequestItems[tableName]; len(retried) != 1 {
+		t.Fatalf("expected the retry to carry only the 1 unprocessed item, got %d", len(retried))
+	}
+}
+
+// TestWriteBatch_GatesReadingsForSameSensorWithinBatch guards against the bug where two readings
+// for the same sensor landing in the same batch (SQS doesn't guarantee ordering) were only gated
+// against last_reading_timestamp as it stood in DynamoDB before the batch started, never against
+// each other.
+func TestWriteBatch_GatesReadingsForSameSensorWithinBatch(t *testing.T) {
+	fake := &fakeDynamoDBAPI{
+		queryFn: func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{
+					{
+						"PK":    &types.AttributeValueMemberS{Value: accountPK("account-1")},
+						"name":  &types.AttributeValueMemberS{Value: "relay-1"},
+						"state": &types.AttributeValueMemberS{Value: "active"},
+					},
+				},
+			}, nil
+		},
+		getItemFn: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{
+				Item: map[string]types.AttributeValue{
+					"name":             &types.AttributeValueMemberS{Value: "sensor-1"},
+					"state":            &types.AttributeValueMemberS{Value: "active"},
+					"location_enabled": &types.AttributeValueMemberBOOL{Value: false},
+					"sample_frequency": &types.AttributeValueMemberN{Value: "60"},
+				},
+			}, nil
+		},
+	}
+
+	writer := NewBatchWriter(fake, BatchWriterConfig{BatchSize: 25, MaxInFlight: 1})
+	messages := []*SensorReadingQueueMessage{
+		{RelayID: "relay-1", SensorID: "sensor-1", ReadingTimestamp: 1000, Measurements: []Measurement{{Name: "temperature", Value: 20}}},
+		{RelayID: "relay-1", SensorID: "sensor-1", ReadingTimestamp: 1010, Measurements: []Measurement{{Name: "temperature", Value: 21}}},
+	}
+
+	results := writer.WriteBatch(context.Background(), messages)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("result %d: unexpected error: %v", i, result.Err)
+		}
+	}
+
+	if len(fake.batchWriteItemInputs) != 1 {
+		t.Fatalf("expected a single BatchWriteItem call, got %d", len(fake.batchWriteItemInputs))
+	}
+	written := fake.batchWriteItemInputs[0].RequestItems[tableName]
+	if len(written) != 1 {
+		t.Fatalf("expected only the first reading to be written - the second arrives only 10s later against a 60s sample frequency and should be gated against it, got %d items", len(written))
+	}
+}
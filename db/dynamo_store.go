@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// dynamoStore is the Storage implementation backed by the single-table DynamoDB design described
+// in repository.go: relays, sensors, raw readings, and hourly rollups all live in one table, keyed
+// by a composite PK/SK, rather than a new table being created per calendar month.
+type dynamoStore struct {
+	repo *repository
+}
+
+// NewDynamoStore builds a Storage backed by DynamoDB
+func NewDynamoStore(dynamoDBService DynamoDBAPI) Storage {
+	return &dynamoStore{repo: newRepository(dynamoDBService)}
+}
+
+// WriteSensorReading will record the Sensor Reading data, first verifying that a corresponding reporting
+// device and account exist and are active
+func (d *dynamoStore) WriteSensorReading(ctx context.Context, r *SensorReadingQueueMessage) error {
+	start := time.Now()
+	defer func() { writeSensorReadingDuration.Observe(time.Since(start).Seconds()) }()
+
+	sensor, readingTimestamp, err := d.prepareReading(ctx, r)
+	if err != nil {
+		return err
+	}
+	if sensor == nil {
+		// sample frequency gate says this reading should be silently dropped
+		return nil
+	}
+
+	// Write measurements to database
+	if err = d.RecordMeasurement(ctx, r, sensor, readingTimestamp); err != nil {
+		return err
+	}
+	return d.RecordHourlyMinMax(ctx, r, sensor, readingTimestamp)
+}
+
+// prepareReading validates r and resolves the sensor it should be recorded against via the shared
+// prepareSensorReading helper, auto-creating the sensor on its first reading. It is shared by
+// WriteSensorReading and BatchWriter so both paths apply the same validation and sample-frequency
+// gating before a reading is persisted.
+func (d *dynamoStore) prepareReading(ctx context.Context, r *SensorReadingQueueMessage) (*Sensor, *time.Time, error) {
+	return prepareSensorReading(ctx, d, d, r)
+}
+
+// GetRelay looks up the relay with the given ID
+func (d *dynamoStore) GetRelay(ctx context.Context, relayID string) (*Relay, error) {
+	return d.repo.getRelay(ctx, relayID)
+}
+
+// GetSensor looks up the sensor with the given ID, scoped to the given account
+func (d *dynamoStore) GetSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	return d.repo.getSensor(ctx, sensorID, accountID)
+}
+
+// RecordMeasurement persists the raw measurement and bumps the sensor's last-seen timestamp, both
+// in the single transaction issued by repository.recordReading
+func (d *dynamoStore) RecordMeasurement(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	return d.repo.recordReading(ctx, r, sensor, readingTimestamp)
+}
+
+// RecordHourlyMinMax folds each measurement in r into its hourly min/max row via an atomic,
+// conditional UpdateItem - see repository.updateHourlyMinMax
+func (d *dynamoStore) RecordHourlyMinMax(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	hourStart := time.Date(readingTimestamp.Year(), readingTimestamp.Month(), readingTimestamp.Day(), readingTimestamp.Hour(), 0, 0, 0, readingTimestamp.Location()).Unix()
+
+	for _, m := range r.Measurements {
+		if err := d.repo.updateHourlyMinMax(ctx, sensor, hourStart, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dynamoStore) createSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	if err := d.repo.createSensor(ctx, sensorID, accountID); err != nil {
+		slog.Error("Encountered error adding new sensor", "sensor_id", sensorID, "account_id", accountID, "table", tableName, "error", err)
+		return nil, err
+	}
+	sensorsAutoCreatedTotal.Inc()
+
+	return &Sensor{
+		ID:              sensorID,
+		AccountID:       accountID,
+		Name:            " ",
+		State:           "active",
+		SampleFrequency: 60,
+	}, nil
+}
+
+// Sensor represents a Sensor capable of taking measurements
+type Sensor struct {
+	ID                   string  `json:"id"`
+	AccountID            string  `json:"account_id"`
+	Name                 string  `json:"name"`
+	State                string  `json:"state"`
+	SampleFrequency      int64   `json:"sample_frequency"`
+	LocationEnabled      bool    `json:"location_enabled"`
+	Latitude             float64 `json:"latitude,omitempty"`
+	Longitude            float64 `json:"longitude,omitempty"`
+	LastReadingTimestamp int64   `json:"last_reading_timestamp,omitempty"`
+}
+
+// Account reprensets a user account
+type Account struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Measurement contains measurement details
+type Measurement struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// MinMaxMeasurement has minimum & maximum measurements readings
+type MinMaxMeasurement struct {
+	Name string      `json:"name"`
+	Min  Measurement `json:"min"`
+	Max  Measurement `json:"max"`
+}
+
+// SensorReadingQueueMessage represnets a sensor reading message sitting on the queue
+type SensorReadingQueueMessage struct {
+	RelayID            string        `json:"relay_id"`
+	SensorID           string        `json:"sensor_id"`
+	ReadingTimestamp   int32         `json:"reading_timestamp"`
+	ReportingTimestamp int32         `json:"reporting_timestamp"`
+	Measurements       []Measurement `json:"measurements"`
+}
+
+// Relay represents a StreamMarker relay
+type Relay struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+}
+
+func (r *Relay) isActive() bool {
+	return (r.State == "active")
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// fakeDynamoDBAPI is a minimal, configurable DynamoDBAPI double for tests that would otherwise
+// need to dial real DynamoDB. Every call is recorded; if the matching *Fn hook is set it decides
+// the response, otherwise a zero-value output and no error are returned.
+type fakeDynamoDBAPI struct {
+	getItemInputs            []*dynamodb.GetItemInput
+	putItemInputs            []*dynamodb.PutItemInput
+	queryInputs              []*dynamodb.QueryInput
+	updateItemInputs         []*dynamodb.UpdateItemInput
+	batchWriteItemInputs     []*dynamodb.BatchWriteItemInput
+	transactWriteItemsInputs []*dynamodb.TransactWriteItemsInput
+
+	getItemFn            func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	putItemFn            func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	queryFn              func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	updateItemFn         func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	batchWriteItemFn     func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	transactWriteItemsFn func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+func (f *fakeDynamoDBAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.getItemInputs = append(f.getItemInputs, params)
+	if f.getItemFn != nil {
+		return f.getItemFn(params)
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (f *fakeDynamoDBAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.putItemInputs = append(f.putItemInputs, params)
+	if f.putItemFn != nil {
+		return f.putItemFn(params)
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDBAPI) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queryInputs = append(f.queryInputs, params)
+	if f.queryFn != nil {
+		return f.queryFn(params)
+	}
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (f *fakeDynamoDBAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.updateItemInputs = append(f.updateItemInputs, params)
+	if f.updateItemFn != nil {
+		return f.updateItemFn(params)
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (f *fakeDynamoDBAPI) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.batchWriteItemInputs = append(f.batchWriteItemInputs, params)
+	if f.batchWriteItemFn != nil {
+		return f.batchWriteItemFn(params)
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (f *fakeDynamoDBAPI) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.transactWriteItemsInputs = append(f.transactWriteItemsInputs, params)
+	if f.transactWriteItemsFn != nil {
+		return f.transactWriteItemsFn(params)
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryStore is an in-memory Storage implementation for use in tests, so callers can exercise
+// WriteSensorReading without standing up DynamoDB or a time-series database.
+type memoryStore struct {
+	mu sync.Mutex
+
+	relays       map[string]*Relay
+	sensors      map[string]*Sensor
+	measurements map[string][]*SensorReadingQueueMessage
+	hourlyMinMax map[string][]MinMaxMeasurement
+}
+
+// NewMemoryStore builds an in-memory Storage, seeded with the given relays and sensors
+func NewMemoryStore(relays []*Relay, sensors []*Sensor) Storage {
+	m := &memoryStore{
+		relays:       make(map[string]*Relay),
+		sensors:      make(map[string]*Sensor),
+		measurements: make(map[string][]*SensorReadingQueueMessage),
+		hourlyMinMax: make(map[string][]MinMaxMeasurement),
+	}
+	for _, r := range relays {
+		m.relays[r.ID] = r
+	}
+	for _, s := range sensors {
+		m.sensors[s.ID] = s
+	}
+	return m
+}
+
+// WriteSensorReading mirrors dynamoStore.WriteSensorReading against the in-memory maps, sharing
+// the same validation, auto-create, and sample-frequency gating via prepareSensorReading
+func (m *memoryStore) WriteSensorReading(ctx context.Context, r *SensorReadingQueueMessage) error {
+	sensor, readingTimestamp, err := prepareSensorReading(ctx, m, m, r)
+	if err != nil {
+		return err
+	}
+	if sensor == nil {
+		// sample frequency gate says this reading should be silently dropped
+		return nil
+	}
+
+	if err := m.RecordMeasurement(ctx, r, sensor, readingTimestamp); err != nil {
+		return err
+	}
+	return m.RecordHourlyMinMax(ctx, r, sensor, readingTimestamp)
+}
+
+// createSensor adds a new sensor to the in-memory store, associating it with accountID
+func (m *memoryStore) createSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	sensor := &Sensor{ID: sensorID, AccountID: accountID, State: "active", SampleFrequency: 60}
+	m.mu.Lock()
+	m.sensors[sensor.ID] = sensor
+	m.mu.Unlock()
+	return sensor, nil
+}
+
+// GetRelay looks up the relay with the given ID
+func (m *memoryStore) GetRelay(ctx context.Context, relayID string) (*Relay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	relay, ok := m.relays[relayID]
+	if !ok {
+		return nil, fmt.Errorf("Relay not found: %s", relayID)
+	}
+	return relay, nil
+}
+
+// GetSensor looks up the sensor with the given ID. accountID is not used, so a sensor owned by a
+// different account is still returned.
+func (m *memoryStore) GetSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sensors[sensorID], nil
+}
+
+// RecordMeasurement appends r to the in-memory history for sensor
+func (m *memoryStore) RecordMeasurement(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := fmt.Sprintf("%s:%s", sensor.AccountID, sensor.ID)
+	m.measurements[key] = append(m.measurements[key], r)
+	return nil
+}
+
+// RecordHourlyMinMax merges r's measurements into the in-memory hourly min/max record for sensor
+func (m *memoryStore) RecordHourlyMinMax(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hourStart := time.Date(readingTimestamp.Year(), readingTimestamp.Month(), readingTimestamp.Day(), readingTimestamp.Hour(), 0, 0, 0, readingTimestamp.Location()).Unix()
+	key := fmt.Sprintf("%s:%s:%d", sensor.AccountID, sensor.ID, hourStart)
+	existing := m.hourlyMinMax[key]
+
+	for _, reading := range r.Measurements {
+		found := false
+		for i, mm := range existing {
+			if mm.Name != reading.Name {
+				continue
+			}
+			found = true
+			if reading.Value < mm.Min.Value {
+				existing[i].Min = reading
+			}
+			if reading.Value > mm.Max.Value {
+				existing[i].Max = reading
+			}
+			break
+		}
+		if !found {
+			existing = append(existing, MinMaxMeasurement{Name: reading.Name, Min: reading, Max: reading})
+		}
+	}
+
+	m.hourlyMinMax[key] = existing
+	return nil
+}
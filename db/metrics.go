@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds every metric the db package exports. It's kept private - MetricsHandler is the
+// only way a caller reaches it, mirroring how DynamoDBAPI is the only way a caller reaches the
+// underlying DynamoDB client.
+var registry = prometheus.NewRegistry()
+
+var (
+	dynamoOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "streammarker_dynamo_operations_total",
+		Help: "DynamoDB operations issued by the db package, labelled by table, operation, and outcome (success, throttled, error).",
+	}, []string{"table", "operation", "outcome"})
+
+	dynamoOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "streammarker_dynamo_operation_duration_seconds",
+		Help:    "Latency of individual DynamoDB operations issued by the db package.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"table", "operation"})
+
+	writeSensorReadingDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "streammarker_write_sensor_reading_duration_seconds",
+		Help:    "End-to-end latency of WriteSensorReading, from validation through the hourly rollup.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	sensorsAutoCreatedTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "streammarker_sensors_auto_created_total",
+		Help: "Sensors implicitly created because a reading arrived for a sensor ID that didn't exist yet.",
+	})
+
+	readingsDroppedSampleFrequencyTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "streammarker_readings_dropped_sample_frequency_total",
+		Help: "Readings ignored because they arrived before the sensor's configured sample frequency allows.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		dynamoOperationsTotal,
+		dynamoOperationDuration,
+		writeSensorReadingDuration,
+		sensorsAutoCreatedTotal,
+		readingsDroppedSampleFrequencyTotal,
+	)
+}
+
+// MetricsHandler returns an http.Handler serving the db package's Prometheus metrics, for the
+// writer binary to mount on /metrics
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// observeDynamoCall times fn - a single DynamoDB operation issued against table - and records its
+// duration and outcome (success, throttled, or error)
+func observeDynamoCall(ctx context.Context, table string, operation string, fn func() error) error {
+	return observeDynamoCallResult(ctx, table, operation, fn, nil)
+}
+
+// observeDynamoCallResult is observeDynamoCall plus an outcomeOverride, for operations where
+// DynamoDB can signal throttling without returning an error - BatchWriteItem reports a partially
+// throttled request via a non-empty UnprocessedItems on an otherwise successful response.
+// outcomeOverride is only consulted when fn succeeds, and only overrides the outcome when it
+// returns a non-empty string.
+func observeDynamoCallResult(ctx context.Context, table string, operation string, fn func() error, outcomeOverride func() string) error {
+	start := time.Now()
+	err := fn()
+	dynamoOperationDuration.WithLabelValues(table, operation).Observe(time.Since(start).Seconds())
+
+	outcome := dynamoOutcome(err)
+	if err == nil && outcomeOverride != nil {
+		if override := outcomeOverride(); override != "" {
+			outcome = override
+		}
+	}
+	dynamoOperationsTotal.WithLabelValues(table, operation, outcome).Inc()
+	return err
+}
+
+func dynamoOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	if isThrottlingError(err) {
+		return "throttled"
+	}
+	return "error"
+}
+
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "ProvisionedThroughputExceededException", "ThrottlingException", "RequestLimitExceeded":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// sampleFrequencyTolerance lets a reading arrive a few seconds early and still count, rather than
+// rejecting anything that doesn't land exactly on the sensor's sample frequency boundary
+const sampleFrequencyTolerance = 3
+
+// sensorAutoCreator is implemented by metadata stores capable of creating a sensor record on its
+// first reading. dynamoStore and memoryStore both satisfy it.
+type sensorAutoCreator interface {
+	createSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error)
+}
+
+// prepareSensorReading runs the validation every Storage implementation needs before persisting a
+// reading: resolve the relay, resolve (or auto-create via creator) the sensor, and apply the
+// sample-frequency gate. It's shared by dynamoStore, timeSeriesStore, and memoryStore so all three
+// reject/auto-create/throttle identically regardless of which backend ultimately stores the
+// reading. A nil sensor with a nil error means the reading should be silently dropped because it
+// arrived before the sensor's configured sample frequency allows.
+func prepareSensorReading(ctx context.Context, metadata Storage, creator sensorAutoCreator, r *SensorReadingQueueMessage) (*Sensor, *time.Time, error) {
+	if len(r.Measurements) == 0 {
+		return nil, nil, errors.New("No measurements provided in message, ignoring")
+	}
+
+	relay, err := metadata.GetRelay(ctx, r.RelayID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !relay.isActive() {
+		return nil, nil, errors.New("Reporting device is not active, will not record sensor reading")
+	}
+
+	sensor, err := metadata.GetSensor(ctx, r.SensorID, relay.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// if the sensor doesn't exist, then create it and associate with the relay account
+	if sensor == nil {
+		slog.Info("Sensor not found, adding", "sensor_id", r.SensorID, "account_id", relay.AccountID, "relay_id", r.RelayID)
+		if sensor, err = creator.createSensor(ctx, r.SensorID, relay.AccountID); err != nil {
+			return nil, nil, err
+		}
+	} else if relay.AccountID != sensor.AccountID {
+		slog.Warn("Sensor and Relay use different account IDs, ignoring", "sensor_id", sensor.ID, "account_id", sensor.AccountID, "relay_id", r.RelayID, "relay_account_id", relay.AccountID)
+		return nil, nil, errors.New("Sensor and Relay use different account IDs, ignoring")
+	}
+
+	// check whether the sample frequency indicates we should ignore this reading
+	readingTimestamp := time.Unix(int64(r.ReadingTimestamp), 0)
+	if !shouldEvaluateSensorReading(&readingTimestamp, sensor) {
+		return nil, nil, nil
+	}
+	return sensor, &readingTimestamp, nil
+}
+
+// shouldEvaluateSensorReading reports whether a reading at readingTimestamp is far enough past
+// sensor's last recorded reading to honor its configured sample frequency
+func shouldEvaluateSensorReading(readingTimestamp *time.Time, sensor *Sensor) bool {
+	if sensor.LastReadingTimestamp == 0 {
+		return true
+	}
+
+	secondsElapsed := float64(readingTimestamp.Unix() - sensor.LastReadingTimestamp)
+	sampleFrequency := sensor.SampleFrequency
+	slog.Debug("Seconds since last reading was written", "sensor_id", sensor.ID, "account_id", sensor.AccountID, "seconds_elapsed", int64(secondsElapsed))
+	if secondsElapsed < float64(sampleFrequency-sampleFrequencyTolerance) {
+		slog.Info("Ignoring reading due to sample frequency limit", "sensor_id", sensor.ID, "account_id", sensor.AccountID, "sample_frequency", sampleFrequency)
+		readingsDroppedSampleFrequencyTotal.Inc()
+		return false
+	}
+	return true
+}
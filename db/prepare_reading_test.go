@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrepareSensorReading_AutoCreatesUnseenSensor(t *testing.T) {
+	relay := &Relay{ID: "relay-1", AccountID: "account-1", State: "active"}
+	store := NewMemoryStore([]*Relay{relay}, nil).(*memoryStore)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-1", SensorID: "sensor-1", ReadingTimestamp: 1000, Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+
+	sensor, readingTimestamp, err := prepareSensorReading(context.Background(), store, store, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sensor == nil || sensor.ID != "sensor-1" || sensor.AccountID != "account-1" {
+		t.Fatalf("expected sensor-1 to be auto-created under account-1, got %+v", sensor)
+	}
+	if readingTimestamp == nil || readingTimestamp.Unix() != 1000 {
+		t.Fatalf("expected reading timestamp 1000, got %v", readingTimestamp)
+	}
+}
+
+func TestPrepareSensorReading_RejectsAccountMismatch(t *testing.T) {
+	relay := &Relay{ID: "relay-1", AccountID: "account-1", State: "active"}
+	sensor := &Sensor{ID: "sensor-2", AccountID: "other-account", State: "active", SampleFrequency: 60}
+	store := NewMemoryStore([]*Relay{relay}, []*Sensor{sensor}).(*memoryStore)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-1", SensorID: "sensor-2", ReadingTimestamp: 1000, Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+
+	if _, _, err := prepareSensorReading(context.Background(), store, store, msg); err == nil {
+		t.Fatal("expected an error for a sensor owned by a different account than the relay")
+	}
+}
+
+func TestPrepareSensorReading_DropsReadingsBelowSampleFrequency(t *testing.T) {
+	relay := &Relay{ID: "relay-1", AccountID: "account-1", State: "active"}
+	sensor := &Sensor{ID: "sensor-3", AccountID: "account-1", State: "active", SampleFrequency: 60, LastReadingTimestamp: 1000}
+	store := NewMemoryStore([]*Relay{relay}, []*Sensor{sensor}).(*memoryStore)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-1", SensorID: "sensor-3", ReadingTimestamp: 1010, Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+
+	resultSensor, readingTimestamp, err := prepareSensorReading(context.Background(), store, store, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resultSensor != nil || readingTimestamp != nil {
+		t.Fatalf("expected the reading to be silently dropped, got sensor %+v timestamp %v", resultSensor, readingTimestamp)
+	}
+}
+
+func TestPrepareSensorReading_RejectsInactiveRelay(t *testing.T) {
+	relay := &Relay{ID: "relay-2", AccountID: "account-1", State: "inactive"}
+	store := NewMemoryStore([]*Relay{relay}, nil).(*memoryStore)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-2", SensorID: "sensor-4", ReadingTimestamp: 1000, Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+
+	if _, _, err := prepareSensorReading(context.Background(), store, store, msg); err == nil {
+		t.Fatal("expected an error for an inactive relay")
+	}
+}
@@ -0,0 +1,441 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Single-table design: every item relevant to an account lives in one DynamoDB table, keyed by a
+// composite PK/SK, instead of being scattered across dynamically-named per-month tables.
+//
+//	PK=ACCOUNT#<accountID>  SK=RELAY#<relayID>                  relay record
+//	PK=ACCOUNT#<accountID>  SK=SENSOR#<sensorID>                 sensor record (incl. last_reading_timestamp)
+//	PK=ACCOUNT#<accountID>  SK=SENSOR#<sensorID>#READING#<ts>    raw reading, zero-padded ts for sort order
+//	PK=ACCOUNT#<accountID>  SK=SENSOR#<sensorID>#HOURLY#<hour>   hourly min/max rollup
+//
+// A global secondary index projects SK as its own partition key (skIndexName), so a relay can be
+// looked up by ID alone before its account is known.
+const (
+	tableName   = "streammarker"
+	skIndexName = "sk-index"
+)
+
+func accountPK(accountID string) string {
+	return fmt.Sprintf("ACCOUNT#%s", accountID)
+}
+
+func accountIDFromPK(pk string) string {
+	return strings.TrimPrefix(pk, "ACCOUNT#")
+}
+
+func relaySK(relayID string) string {
+	return fmt.Sprintf("RELAY#%s", relayID)
+}
+
+func sensorSK(sensorID string) string {
+	return fmt.Sprintf("SENSOR#%s", sensorID)
+}
+
+func readingSK(sensorID string, timestamp int64) string {
+	return fmt.Sprintf("SENSOR#%s#READING#%020d", sensorID, timestamp)
+}
+
+func hourlySK(sensorID string, hourStart int64) string {
+	return fmt.Sprintf("SENSOR#%s#HOURLY#%020d", sensorID, hourStart)
+}
+
+// repository issues the DynamoDB calls needed to satisfy Storage against the single streammarker
+// table, translating between the domain types (Relay, Sensor, ...) and the PK/SK scheme above.
+type repository struct {
+	dynamoDBService DynamoDBAPI
+}
+
+func newRepository(dynamoDBService DynamoDBAPI) *repository {
+	return &repository{dynamoDBService: dynamoDBService}
+}
+
+// getRelay looks up a relay by ID alone via the SK GSI, since the caller doesn't yet know which
+// account the relay belongs to
+func (repo *repository) getRelay(ctx context.Context, relayID string) (*Relay, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(skIndexName),
+		KeyConditionExpression: aws.String("SK = :sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sk": &types.AttributeValueMemberS{Value: relaySK(relayID)},
+		},
+		Limit: aws.Int32(1),
+	}
+
+	var resp *dynamodb.QueryOutput
+	err := observeDynamoCall(ctx, tableName, "Query", func() error {
+		var queryErr error
+		resp, queryErr = repo.dynamoDBService.Query(ctx, params)
+		return queryErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, fmt.Errorf("Relay not found: %s", relayID)
+	}
+
+	item := resp.Items[0]
+	return &Relay{
+		ID:        relayID,
+		AccountID: accountIDFromPK(item["PK"].(*types.AttributeValueMemberS).Value),
+		Name:      item["name"].(*types.AttributeValueMemberS).Value,
+		State:     item["state"].(*types.AttributeValueMemberS).Value,
+	}, nil
+}
+
+// getSensor looks up the sensor with the given ID directly, since accountID is already known by
+// the time a caller needs it
+func (repo *repository) getSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	params := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: accountPK(accountID)},
+			"SK": &types.AttributeValueMemberS{Value: sensorSK(sensorID)},
+		},
+	}
+
+	var resp *dynamodb.GetItemOutput
+	err := observeDynamoCall(ctx, tableName, "GetItem", func() error {
+		var getErr error
+		resp, getErr = repo.dynamoDBService.GetItem(ctx, params)
+		return getErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+
+	sensor := &Sensor{
+		ID:              sensorID,
+		AccountID:       accountID,
+		Name:            resp.Item["name"].(*types.AttributeValueMemberS).Value,
+		State:           resp.Item["state"].(*types.AttributeValueMemberS).Value,
+		LocationEnabled: resp.Item["location_enabled"].(*types.AttributeValueMemberBOOL).Value,
+	}
+	if sampleFrequency, ok := resp.Item["sample_frequency"]; ok {
+		sensor.SampleFrequency, _ = strconv.ParseInt(sampleFrequency.(*types.AttributeValueMemberN).Value, 10, 64)
+	} else {
+		sensor.SampleFrequency = 60
+	}
+	if lastSeen, ok := resp.Item["last_reading_timestamp"]; ok {
+		sensor.LastReadingTimestamp, _ = strconv.ParseInt(lastSeen.(*types.AttributeValueMemberN).Value, 10, 64)
+	}
+	latitude, hasLatitude := resp.Item["latitude"]
+	longitude, hasLongitude := resp.Item["longitude"]
+	if hasLatitude && hasLongitude {
+		sensor.Latitude, _ = strconv.ParseFloat(latitude.(*types.AttributeValueMemberN).Value, 64)
+		sensor.Longitude, _ = strconv.ParseFloat(longitude.(*types.AttributeValueMemberN).Value, 64)
+	}
+	return sensor, nil
+}
+
+// createSensor writes a new sensor record, associating it with accountID
+func (repo *repository) createSensor(ctx context.Context, sensorID string, accountID string) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"PK":               &types.AttributeValueMemberS{Value: accountPK(accountID)},
+			"SK":               &types.AttributeValueMemberS{Value: sensorSK(sensorID)},
+			"account_id":       &types.AttributeValueMemberS{Value: accountID},
+			"name":             &types.AttributeValueMemberS{Value: " "},
+			"state":            &types.AttributeValueMemberS{Value: "active"},
+			"sample_frequency": &types.AttributeValueMemberN{Value: "1"},
+			"location_enabled": &types.AttributeValueMemberBOOL{Value: false},
+		},
+	}
+	return observeDynamoCall(ctx, tableName, "PutItem", func() error {
+		_, err := repo.dynamoDBService.PutItem(ctx, input)
+		return err
+	})
+}
+
+// readingItem builds the raw reading row for r, keyed and shaped the same way whether it's written
+// on its own (recordReading) or folded into a BatchWriteItem request (BatchWriter)
+func (repo *repository) readingItem(r *SensorReadingQueueMessage, sensor *Sensor) (map[string]types.AttributeValue, error) {
+	measurementsJSON, err := json.Marshal(r.Measurements)
+	if err != nil {
+		return nil, err
+	}
+
+	item := map[string]types.AttributeValue{
+		"PK":           &types.AttributeValueMemberS{Value: accountPK(sensor.AccountID)},
+		"SK":           &types.AttributeValueMemberS{Value: readingSK(sensor.ID, int64(r.ReadingTimestamp))},
+		"account_id":   &types.AttributeValueMemberS{Value: sensor.AccountID},
+		"sensor_id":    &types.AttributeValueMemberS{Value: sensor.ID},
+		"relay_id":     &types.AttributeValueMemberS{Value: r.RelayID},
+		"measurements": &types.AttributeValueMemberS{Value: string(measurementsJSON)},
+	}
+	if sensor.LocationEnabled && sensor.Latitude != 0 && sensor.Longitude != 0 {
+		item["latitude"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", sensor.Latitude)}
+		item["longitude"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", sensor.Longitude)}
+	}
+	return item, nil
+}
+
+// recordReading atomically writes the raw reading and bumps the sensor's last-seen timestamp in a
+// single transaction, replacing the sequence of independent puts (and ListTables/CreateTable
+// fallback) the monthly-table design needed. The hourly min/max rollup is updated separately, one
+// conditional UpdateItem per measurement - see updateHourlyMinMax.
+func (repo *repository) recordReading(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	readingItem, err := repo.readingItem(r, sensor)
+	if err != nil {
+		return err
+	}
+
+	err = observeDynamoCall(ctx, tableName, "TransactWriteItems", func() error {
+		_, err := repo.dynamoDBService.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Put: &types.Put{
+						TableName: aws.String(tableName),
+						Item:      readingItem,
+					},
+				},
+				{
+					Update: &types.Update{
+						TableName: aws.String(tableName),
+						Key: map[string]types.AttributeValue{
+							"PK": &types.AttributeValueMemberS{Value: accountPK(sensor.AccountID)},
+							"SK": &types.AttributeValueMemberS{Value: sensorSK(sensor.ID)},
+						},
+						UpdateExpression:    aws.String("SET last_reading_timestamp = :ts"),
+						ConditionExpression: aws.String("attribute_not_exists(last_reading_timestamp) OR last_reading_timestamp < :ts"),
+						ExpressionAttributeValues: map[string]types.AttributeValue{
+							":ts": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", r.ReadingTimestamp)},
+						},
+					},
+				},
+			},
+		})
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+
+	// SQS doesn't guarantee ordering, so a late reading can reach here after a newer one has
+	// already bumped last_reading_timestamp. The condition above catches that and cancels the
+	// transaction - the correct outcome, since we don't want to roll the timestamp backwards - but
+	// TransactWriteItems cancels every item in the transaction when one condition fails, including
+	// the reading Put. Retry with just the Put so the out-of-order reading is still recorded.
+	if lastReadingTimestampConditionFailed(err) {
+		return observeDynamoCall(ctx, tableName, "PutItem", func() error {
+			_, putErr := repo.dynamoDBService.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName: aws.String(tableName),
+				Item:      readingItem,
+			})
+			return putErr
+		})
+	}
+	return err
+}
+
+// lastReadingTimestampConditionFailed reports whether err is a TransactWriteItems cancellation
+// caused specifically by the last_reading_timestamp conditional update losing a race against a
+// newer reading, rather than some other failure in the transaction
+func lastReadingTimestampConditionFailed(err error) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	if len(canceled.CancellationReasons) < 2 {
+		return false
+	}
+	reason := canceled.CancellationReasons[1]
+	return reason.Code != nil && *reason.Code == "ConditionalCheckFailed"
+}
+
+// maxMinMaxRetries bounds how many times updateHourlyMinMax retries a bound update after it fails
+// for any reason other than its own condition check
+const maxMinMaxRetries = 3
+
+// updateHourlyMinMax atomically folds m into the hourly min/max row for sensor at hourStart. Each
+// bound (min and max) is its own conditional UpdateItem - attribute_not_exists(...) OR the stored
+// bound is beaten by m.Value - so two writers racing on the same sensor/hour converge on the
+// correct min and max without either one reading the row first.
+func (repo *repository) updateHourlyMinMax(ctx context.Context, sensor *Sensor, hourStart int64, m Measurement) error {
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: accountPK(sensor.AccountID)},
+		"SK": &types.AttributeValueMemberS{Value: hourlySK(sensor.ID, hourStart)},
+	}
+
+	if err := repo.updateMinMaxBound(ctx, key, sensor, m, "min", ">"); err != nil {
+		return err
+	}
+	return repo.updateMinMaxBound(ctx, key, sensor, m, "max", "<")
+}
+
+// updateMinMaxBound sets attribute "<bound>_<measurement name>" to m.Value, guarded by a condition
+// that only allows the write when the attribute is missing or m.Value actually beats it. A failed
+// condition here means the stored value already wins - that's the correct outcome, not a race, so
+// it isn't treated as an error. A real failure (table/row missing the expected shape) is repaired
+// by seeding a bare row with createHourlyRow and retried.
+func (repo *repository) updateMinMaxBound(ctx context.Context, key map[string]types.AttributeValue, sensor *Sensor, m Measurement, bound string, beatenBy string) error {
+	attrName := fmt.Sprintf("%s_%s", bound, m.Name)
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #attr = :v, account_id = :accountID, sensor_id = :sensorID"),
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_not_exists(#attr) OR #attr %s :v", beatenBy)),
+		ExpressionAttributeNames: map[string]string{
+			"#attr": attrName,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v":         &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", m.Value)},
+			":accountID": &types.AttributeValueMemberS{Value: sensor.AccountID},
+			":sensorID":  &types.AttributeValueMemberS{Value: sensor.ID},
+		},
+	}
+
+	var err error
+	for attempt := 0; attempt <= maxMinMaxRetries; attempt++ {
+		err = observeDynamoCall(ctx, tableName, "UpdateItem", func() error {
+			_, updateErr := repo.dynamoDBService.UpdateItem(ctx, input)
+			return updateErr
+		})
+		if err == nil {
+			return nil
+		}
+
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			// the stored bound already beats ours - nothing to do, this is the correct outcome
+			return nil
+		}
+
+		// unexpected failure (e.g. the row doesn't exist in the shape the update expects); seed a
+		// bare row and retry, but only a bounded number of times
+		if putErr := repo.createHourlyRow(ctx, key, sensor); putErr != nil {
+			var rowAlreadyExists *types.ConditionalCheckFailedException
+			if !errors.As(putErr, &rowAlreadyExists) {
+				return putErr
+			}
+		}
+	}
+	return err
+}
+
+// createHourlyRow seeds a bare hourly rollup row so subsequent conditional updates have a row to
+// attach to. If another writer created the row first, the row is left unchanged and the put fails
+// with a ConditionalCheckFailedException.
+func (repo *repository) createHourlyRow(ctx context.Context, key map[string]types.AttributeValue, sensor *Sensor) error {
+	return observeDynamoCall(ctx, tableName, "PutItem", func() error {
+		_, err := repo.dynamoDBService.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(tableName),
+			Item: map[string]types.AttributeValue{
+				"PK":         key["PK"],
+				"SK":         key["SK"],
+				"account_id": &types.AttributeValueMemberS{Value: sensor.AccountID},
+				"sensor_id":  &types.AttributeValueMemberS{Value: sensor.ID},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		})
+		return err
+	})
+}
+
+// updateLastSeen bumps the sensor's last_reading_timestamp on its own, for callers (BatchWriter)
+// that can't fold it into the same transaction as the reading write. The update is conditioned on
+// readingTimestamp actually being newer than what's stored, so a late, out-of-order reading can't
+// roll the sensor's last-seen timestamp backwards and corrupt the sample-frequency gate for
+// readings that land after it.
+func (repo *repository) updateLastSeen(ctx context.Context, sensor *Sensor, readingTimestamp int64) error {
+	return observeDynamoCall(ctx, tableName, "UpdateItem", func() error {
+		_, err := repo.dynamoDBService.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: accountPK(sensor.AccountID)},
+				"SK": &types.AttributeValueMemberS{Value: sensorSK(sensor.ID)},
+			},
+			UpdateExpression:    aws.String("SET last_reading_timestamp = :ts"),
+			ConditionExpression: aws.String("attribute_not_exists(last_reading_timestamp) OR last_reading_timestamp < :ts"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":ts": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", readingTimestamp)},
+			},
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			// the stored timestamp is already newer - correct outcome, not an error
+			return nil
+		}
+		return err
+	})
+}
+
+// maxBatchWriteItems is the largest number of items a single BatchWriteItem call accepts
+const maxBatchWriteItems = 25
+
+// maxBatchWriteRetries bounds how many times putReadings retries items DynamoDB returns as
+// UnprocessedItems before giving up on them
+const maxBatchWriteRetries = 5
+
+// putReadings writes up to maxBatchWriteItems raw reading rows in a single BatchWriteItem call,
+// retrying any UnprocessedItems - DynamoDB's way of signalling it throttled part of the request -
+// with exponential backoff and jitter until they're all written or maxBatchWriteRetries is spent.
+func (repo *repository) putReadings(ctx context.Context, items []map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, len(items))
+	for i, item := range items {
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	pending := map[string][]types.WriteRequest{tableName: requests}
+	for attempt := 0; attempt <= maxBatchWriteRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleepWithBackoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+
+		var resp *dynamodb.BatchWriteItemOutput
+		err := observeDynamoCallResult(ctx, tableName, "BatchWriteItem", func() error {
+			var batchErr error
+			resp, batchErr = repo.dynamoDBService.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			return batchErr
+		}, func() string {
+			if resp != nil && len(resp.UnprocessedItems) > 0 {
+				return "throttled"
+			}
+			return ""
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.UnprocessedItems) == 0 {
+			return nil
+		}
+		pending = resp.UnprocessedItems
+	}
+	return fmt.Errorf("gave up writing %d reading(s) after %d retries due to unprocessed capacity", len(pending[tableName]), maxBatchWriteRetries)
+}
+
+// sleepWithBackoff waits an exponentially increasing, jittered delay before the next retry
+// attempt, or returns early if ctx is cancelled first
+func sleepWithBackoff(ctx context.Context, attempt int) error {
+	backoff := time.Duration(1<<uint(attempt)) * 100 * time.Millisecond
+	delay := backoff + time.Duration(rand.Int63n(int64(backoff)))
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
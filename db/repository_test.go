@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestUpdateHourlyMinMax_ConditionExpressions guards against regressing the swapped min/max
+// comparison operators fixed in updateMinMaxBound: the min bound must only be overwritten when the
+// new value is smaller (">" in the condition means the stored value beats it), the max bound only
+// when the new value is larger ("<").
+func TestUpdateHourlyMinMax_ConditionExpressions(t *testing.T) {
+	fake := &fakeDynamoDBAPI{}
+	repo := newRepository(fake)
+	sensor := &Sensor{ID: "sensor-1", AccountID: "account-1"}
+
+	if err := repo.updateHourlyMinMax(context.Background(), sensor, 0, Measurement{Name: "temperature", Value: 21.5}); err != nil {
+		t.Fatalf("updateHourlyMinMax returned error: %v", err)
+	}
+
+	if len(fake.updateItemInputs) != 2 {
+		t.Fatalf("expected 2 UpdateItem calls (min, max), got %d", len(fake.updateItemInputs))
+	}
+
+	seenMin, seenMax := false, false
+	for _, input := range fake.updateItemInputs {
+		attrName := input.ExpressionAttributeNames["#attr"]
+		condition := aws.ToString(input.ConditionExpression)
+		switch {
+		case strings.HasPrefix(attrName, "min_"):
+			seenMin = true
+			if !strings.Contains(condition, "#attr > :v") {
+				t.Errorf("min bound condition must require the stored value to beat the new one with >, got %q", condition)
+			}
+		case strings.HasPrefix(attrName, "max_"):
+			seenMax = true
+			if !strings.Contains(condition, "#attr < :v") {
+				t.Errorf("max bound condition must require the stored value to beat the new one with <, got %q", condition)
+			}
+		default:
+			t.Fatalf("unexpected attribute name %q", attrName)
+		}
+	}
+	if !seenMin || !seenMax {
+		t.Fatalf("expected both a min and a max UpdateItem call, got seenMin=%v seenMax=%v", seenMin, seenMax)
+	}
+}
+
+// TestUpdateLastSeen_IgnoresOutOfOrderReading verifies that a reading older than what's already
+// stored is rejected by the conditional update without being reported as an error - SQS doesn't
+// guarantee ordering, so this is the expected, non-exceptional outcome.
+func TestUpdateLastSeen_IgnoresOutOfOrderReading(t *testing.T) {
+	fake := &fakeDynamoDBAPI{
+		updateItemFn: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+	repo := newRepository(fake)
+	sensor := &Sensor{ID: "sensor-1", AccountID: "account-1"}
+
+	if err := repo.updateLastSeen(context.Background(), sensor, 1000); err != nil {
+		t.Fatalf("expected a condition failure to be treated as success, got error: %v", err)
+	}
+}
+
+// TestPutReadings_RecordsPartialThrottlingMetric verifies that a BatchWriteItem response
+// returning UnprocessedItems with no error is counted as "throttled" rather than "success" -
+// DynamoDB's way of signalling partial throttling on this API doesn't raise an error at all.
+func TestPutReadings_RecordsPartialThrottlingMetric(t *testing.T) {
+	before := testutil.ToFloat64(dynamoOperationsTotal.WithLabelValues(tableName, "BatchWriteItem", "throttled"))
+
+	calls := 0
+	fake := &fakeDynamoDBAPI{
+		batchWriteItemFn: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			calls++
+			if calls == 1 {
+				unprocessed := in.RequestItems[tableName][:1]
+				return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{tableName: unprocessed}}, nil
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	repo := newRepository(fake)
+	items := []map[string]types.AttributeValue{
+		{"PK": &types.AttributeValueMemberS{Value: "a"}},
+		{"PK": &types.AttributeValueMemberS{Value: "b"}},
+	}
+	if err := repo.putReadings(context.Background(), items); err != nil {
+		t.Fatalf("putReadings returned error: %v", err)
+	}
+
+	after := testutil.ToFloat64(dynamoOperationsTotal.WithLabelValues(tableName, "BatchWriteItem", "throttled"))
+	if after != before+1 {
+		t.Fatalf("expected the partially-throttled attempt to increment the throttled outcome by 1, went from %v to %v", before, after)
+	}
+}
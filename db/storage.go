@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Storage is implemented by every backend capable of persisting relays,
+// sensors, and the measurements reported for them. Decoupling the pipeline
+// from a single backend lets operators choose the storage engine that suits
+// their write volume and query patterns (DynamoDB's single table vs. a
+// purpose-built time-series database) without touching the ingestion code.
+// Every method takes a context so callers can cancel or time out a write.
+type Storage interface {
+	// WriteSensorReading records the sensor reading data, first verifying that a corresponding reporting
+	// device and account exist and are active
+	WriteSensorReading(ctx context.Context, r *SensorReadingQueueMessage) error
+
+	// GetRelay looks up the relay with the given ID
+	GetRelay(ctx context.Context, relayID string) (*Relay, error)
+
+	// GetSensor looks up the sensor with the given ID, scoped to the given account
+	GetSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error)
+
+	// RecordMeasurement persists the raw measurements contained in r
+	RecordMeasurement(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error
+
+	// RecordHourlyMinMax folds the measurements contained in r into the rolling hourly min/max record
+	RecordHourlyMinMax(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error
+}
+
+// StorageBackend identifies which Storage implementation to construct
+type StorageBackend string
+
+const (
+	// StorageBackendDynamo persists relays, sensors, and readings in DynamoDB
+	StorageBackendDynamo StorageBackend = "dynamo"
+	// StorageBackendInflux persists readings in an InfluxDB-style time-series database
+	StorageBackendInflux StorageBackend = "influx"
+	// StorageBackendTimescale persists readings in a TimescaleDB-style time-series database
+	StorageBackendTimescale StorageBackend = "timescale"
+)
+
+// Config controls which Storage backend NewStorage constructs and how it connects. The caller is
+// expected to have already built the backend-specific client(s), mirroring how NewDynamoStore
+// takes a DynamoDBAPI rather than building one itself.
+type Config struct {
+	Backend StorageBackend
+
+	// DynamoDBService is used when Backend is StorageBackendDynamo, and as the metadata store
+	// (relay/sensor lookups) backing StorageBackendInflux and StorageBackendTimescale
+	DynamoDBService DynamoDBAPI
+
+	// TimeSeries is used when Backend is StorageBackendInflux or StorageBackendTimescale
+	TimeSeries TimeSeriesConfig
+}
+
+// BackendFromEnv returns the StorageBackend configured via the STREAMMARKER_STORAGE environment
+// variable, defaulting to DynamoDB when it is unset so existing deployments keep working unmodified
+func BackendFromEnv() StorageBackend {
+	if backend := StorageBackend(os.Getenv("STREAMMARKER_STORAGE")); backend != "" {
+		return backend
+	}
+	return StorageBackendDynamo
+}
+
+// NewStorage constructs the Storage backend selected by cfg.Backend
+func NewStorage(cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case StorageBackendInflux, StorageBackendTimescale:
+		return NewTimeSeriesStore(cfg.TimeSeries, NewDynamoStore(cfg.DynamoDBService))
+	case StorageBackendDynamo, "":
+		return NewDynamoStore(cfg.DynamoDBService), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q", cfg.Backend)
+	}
+}
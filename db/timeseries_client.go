@@ -0,0 +1,77 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lineProtocolClient writes points to an InfluxDB/TimescaleDB-compatible HTTP write endpoint using
+// the line protocol, avoiding a hard dependency on either database's client library.
+type lineProtocolClient struct {
+	httpClient *http.Client
+	writeURL   string
+	username   string
+	password   string
+}
+
+func newLineProtocolClient(cfg TimeSeriesConfig) (*lineProtocolClient, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("time-series database address not configured")
+	}
+	if cfg.Database == "" {
+		return nil, fmt.Errorf("time-series database name not configured")
+	}
+
+	return &lineProtocolClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		writeURL:   fmt.Sprintf("%s/write?db=%s&precision=s", strings.TrimRight(cfg.Addr, "/"), cfg.Database),
+		username:   cfg.Username,
+		password:   cfg.Password,
+	}, nil
+}
+
+// WritePoints sends points for the given measurement as a single line-protocol batch
+func (c *lineProtocolClient) WritePoints(ctx context.Context, measurement string, points []point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	for _, p := range points {
+		body.WriteString(measurement)
+		for _, tagKey := range []string{"account_id", "sensor_id", "relay_id", "name", "latitude", "longitude"} {
+			if v, ok := p.Tags[tagKey]; ok {
+				fmt.Fprintf(&body, ",%s=%s", tagKey, escapeTagValue(v))
+			}
+		}
+		fmt.Fprintf(&body, " value=%f %d\n", p.Value, p.Timestamp.Unix())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("time-series database write failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func escapeTagValue(v string) string {
+	replacer := strings.NewReplacer(" ", "\\ ", ",", "\\,", "=", "\\=")
+	return replacer.Replace(v)
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// TimeSeriesConfig holds the connection details needed to reach the time-series database backing
+// timeSeriesStore
+type TimeSeriesConfig struct {
+	Addr     string
+	Database string
+	Username string
+	Password string
+
+	// BatchSize caps how many points are buffered before being flushed in a single write
+	BatchSize int
+
+	// FlushInterval is the longest buffered points sit in memory before being flushed, even if
+	// BatchSize hasn't been reached - bounds how much is lost if the process crashes or restarts
+	// between flushes, which otherwise could be hours for a low-volume sensor
+	FlushInterval time.Duration
+}
+
+// TimeSeriesConfigFromEnv builds a TimeSeriesConfig from STREAMMARKER_TIMESERIES_* environment
+// variables
+func TimeSeriesConfigFromEnv() TimeSeriesConfig {
+	batchSize := 500
+	if raw := os.Getenv("STREAMMARKER_TIMESERIES_BATCH_SIZE"); raw != "" {
+		if parsed, err := parsePositiveInt(raw); err == nil {
+			batchSize = parsed
+		}
+	}
+	flushInterval := 5 * time.Second
+	if raw := os.Getenv("STREAMMARKER_TIMESERIES_FLUSH_INTERVAL"); raw != "" {
+		if parsed, err := parsePositiveInt(raw); err == nil {
+			flushInterval = time.Duration(parsed) * time.Second
+		}
+	}
+	return TimeSeriesConfig{
+		Addr:          os.Getenv("STREAMMARKER_TIMESERIES_ADDR"),
+		Database:      os.Getenv("STREAMMARKER_TIMESERIES_DATABASE"),
+		Username:      os.Getenv("STREAMMARKER_TIMESERIES_USERNAME"),
+		Password:      os.Getenv("STREAMMARKER_TIMESERIES_PASSWORD"),
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+	}
+}
+
+// point is a single time-series point, modelled after the line-protocol point used by both
+// InfluxDB and Timescale's `time_value` style hypertables
+type point struct {
+	Tags      map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// pointWriter is the subset of an InfluxDB/TimescaleDB client used by timeSeriesStore, kept narrow
+// so tests can substitute an in-memory fake instead of dialing a real database
+type pointWriter interface {
+	WritePoints(ctx context.Context, measurement string, points []point) error
+}
+
+// Flusher is implemented by Storage backends that buffer writes client-side and so need an
+// explicit flush before a caller can be sure a reading is durable - currently just
+// timeSeriesStore. A caller holding a Storage built by NewTimeSeriesStore can type-assert it to
+// Flusher to force a flush (e.g. before acking a batch of SQS messages) or to flush and stop the
+// periodic flush loop on shutdown.
+type Flusher interface {
+	// Flush forces any buffered points to be written immediately
+	Flush(ctx context.Context) error
+	// Close stops the periodic flush loop and flushes any remaining buffered points
+	Close(ctx context.Context) error
+}
+
+// timeSeriesStore is a Storage implementation backed by an InfluxDB/TimescaleDB-style time-series
+// database. Raw measurements are written as points tagged by account_id/sensor_id/relay_id (and
+// optionally latitude/longitude), batched client-side to avoid a round trip per reading. Relay and
+// sensor metadata, which isn't time-series data, is delegated to an underlying metadata Storage.
+// Buffered points are flushed either once batchSize is reached or every flushInterval, whichever
+// comes first, so a slow trickle of readings doesn't sit unflushed in memory for hours.
+type timeSeriesStore struct {
+	metadata Storage
+	writer   pointWriter
+
+	mu        sync.Mutex
+	buffer    []point
+	batchSize int
+
+	flushInterval time.Duration
+	stop          chan struct{}
+	stopped       sync.WaitGroup
+}
+
+// NewTimeSeriesStore builds a Storage backed by the time-series database described by cfg, using
+// metadata for relay/sensor lookups since those aren't time-series data
+func NewTimeSeriesStore(cfg TimeSeriesConfig, metadata Storage) (Storage, error) {
+	writer, err := newLineProtocolClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to time-series database: %w", err)
+	}
+	return newTimeSeriesStoreWithWriter(cfg, metadata, writer), nil
+}
+
+// newTimeSeriesStoreWithWriter builds a timeSeriesStore around an already-constructed pointWriter,
+// letting tests substitute a fake in place of the real line-protocol HTTP client
+func newTimeSeriesStoreWithWriter(cfg TimeSeriesConfig, metadata Storage, writer pointWriter) *timeSeriesStore {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = 5 * time.Second
+	}
+
+	t := &timeSeriesStore{
+		metadata:      metadata,
+		writer:        writer,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+	}
+	t.stopped.Add(1)
+	go t.runPeriodicFlush()
+	return t
+}
+
+// runPeriodicFlush flushes any buffered points at least every flushInterval. Without it, a
+// process crash or restart between flushes would silently drop every point buffered since the
+// last one - for a low-volume sensor, batchSize can otherwise take hours to fill.
+func (t *timeSeriesStore) runPeriodicFlush() {
+	defer t.stopped.Done()
+
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(context.Background()); err != nil {
+				slog.Error("Error on periodic flush of time-series points", "error", err)
+			}
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// Flush forces any buffered points to be written immediately, satisfying Flusher
+func (t *timeSeriesStore) Flush(ctx context.Context) error {
+	return t.flush(ctx)
+}
+
+// Close stops the periodic flush loop and writes any remaining buffered points, satisfying
+// Flusher. Callers should invoke this during shutdown so readings accumulated since the last
+// flush aren't dropped.
+func (t *timeSeriesStore) Close(ctx context.Context) error {
+	close(t.stop)
+	t.stopped.Wait()
+	return t.flush(ctx)
+}
+
+// WriteSensorReading mirrors dynamoStore.WriteSensorReading - same validation, auto-create, and
+// sample-frequency gating via the shared prepareSensorReading helper - but records measurements as
+// time-series points instead of DynamoDB items
+func (t *timeSeriesStore) WriteSensorReading(ctx context.Context, r *SensorReadingQueueMessage) error {
+	creator, ok := t.metadata.(sensorAutoCreator)
+	if !ok {
+		return fmt.Errorf("metadata store %T cannot auto-create sensors", t.metadata)
+	}
+
+	sensor, readingTimestamp, err := prepareSensorReading(ctx, t.metadata, creator, r)
+	if err != nil {
+		return err
+	}
+	if sensor == nil {
+		// sample frequency gate says this reading should be silently dropped
+		return nil
+	}
+
+	if err := t.RecordMeasurement(ctx, r, sensor, readingTimestamp); err != nil {
+		return err
+	}
+	return t.RecordHourlyMinMax(ctx, r, sensor, readingTimestamp)
+}
+
+// GetRelay delegates to the metadata store, since relay records aren't time-series data
+func (t *timeSeriesStore) GetRelay(ctx context.Context, relayID string) (*Relay, error) {
+	return t.metadata.GetRelay(ctx, relayID)
+}
+
+// GetSensor delegates to the metadata store, since sensor records aren't time-series data
+func (t *timeSeriesStore) GetSensor(ctx context.Context, sensorID string, accountID string) (*Sensor, error) {
+	return t.metadata.GetSensor(ctx, sensorID, accountID)
+}
+
+// RecordMeasurement writes each Measurement in r as its own tagged point, buffering until
+// batchSize points are pending before flushing to the underlying database
+func (t *timeSeriesStore) RecordMeasurement(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	tags := map[string]string{
+		"account_id": sensor.AccountID,
+		"sensor_id":  sensor.ID,
+		"relay_id":   r.RelayID,
+	}
+	if sensor.LocationEnabled && sensor.Latitude != 0 && sensor.Longitude != 0 {
+		tags["latitude"] = fmt.Sprintf("%f", sensor.Latitude)
+		tags["longitude"] = fmt.Sprintf("%f", sensor.Longitude)
+	}
+
+	t.mu.Lock()
+	for _, m := range r.Measurements {
+		t.buffer = append(t.buffer, point{
+			Tags:      withMeasurementName(tags, m.Name),
+			Value:     m.Value,
+			Timestamp: *readingTimestamp,
+		})
+	}
+	shouldFlush := len(t.buffer) >= t.batchSize
+	t.mu.Unlock()
+
+	if shouldFlush {
+		return t.flush(ctx)
+	}
+	return nil
+}
+
+// RecordHourlyMinMax is a no-op for the time-series backend: unlike the DynamoDB store, which has
+// to maintain its own hourly rollup rows, a time-series database can compute min/max over any
+// window directly from the raw points recorded by RecordMeasurement.
+func (t *timeSeriesStore) RecordHourlyMinMax(ctx context.Context, r *SensorReadingQueueMessage, sensor *Sensor, readingTimestamp *time.Time) error {
+	return nil
+}
+
+// flush writes and clears the buffered points, grouped by measurement name
+func (t *timeSeriesStore) flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.buffer
+	t.buffer = nil
+	t.mu.Unlock()
+
+	byName := make(map[string][]point)
+	for _, p := range pending {
+		name := p.Tags["name"]
+		byName[name] = append(byName[name], p)
+	}
+
+	for name, points := range byName {
+		if err := t.writer.WritePoints(ctx, name, points); err != nil {
+			slog.Error("Error writing points to time-series database", "measurement", name, "point_count", len(points), "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func withMeasurementName(tags map[string]string, name string) map[string]string {
+	tagged := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		tagged[k] = v
+	}
+	tagged["name"] = name
+	return tagged
+}
+
+func parsePositiveInt(raw string) (int, error) {
+	var n int
+	if _, err := fmt.Sscanf(raw, "%d", &n); err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("value must be positive: %q", raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePointWriter is a pointWriter double that records every WritePoints call
+type fakePointWriter struct {
+	mu    sync.Mutex
+	calls [][]point
+}
+
+func (f *fakePointWriter) WritePoints(ctx context.Context, measurement string, points []point) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, points)
+	return nil
+}
+
+func (f *fakePointWriter) pointCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, points := range f.calls {
+		count += len(points)
+	}
+	return count
+}
+
+// TestRecordMeasurement_PeriodicFlushReachesPointWriter guards against readings sitting buffered
+// in memory indefinitely: a reading recorded well below batchSize must still reach the
+// pointWriter once flushInterval elapses, without RecordMeasurement or an explicit Flush call
+// forcing it.
+func TestRecordMeasurement_PeriodicFlushReachesPointWriter(t *testing.T) {
+	relay := &Relay{ID: "relay-1", AccountID: "account-1", State: "active"}
+	metadata := NewMemoryStore([]*Relay{relay}, nil)
+	writer := &fakePointWriter{}
+
+	store := newTimeSeriesStoreWithWriter(TimeSeriesConfig{BatchSize: 500, FlushInterval: 20 * time.Millisecond}, metadata, writer)
+	defer store.Close(context.Background())
+
+	sensor := &Sensor{ID: "sensor-1", AccountID: "account-1"}
+	readingTimestamp := time.Unix(1000, 0)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-1", Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+	if err := store.RecordMeasurement(context.Background(), msg, sensor, &readingTimestamp); err != nil {
+		t.Fatalf("RecordMeasurement returned error: %v", err)
+	}
+
+	deadline := time.Now().Add(1 * time.Second)
+	for writer.pointCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if writer.pointCount() != 1 {
+		t.Fatalf("expected the periodic flush to have written the buffered point within 1s, got %d written", writer.pointCount())
+	}
+}
+
+// TestTimeSeriesStore_Close flushes any points still buffered when Close is called
+func TestTimeSeriesStore_Close(t *testing.T) {
+	relay := &Relay{ID: "relay-1", AccountID: "account-1", State: "active"}
+	metadata := NewMemoryStore([]*Relay{relay}, nil)
+	writer := &fakePointWriter{}
+
+	store := newTimeSeriesStoreWithWriter(TimeSeriesConfig{BatchSize: 500, FlushInterval: time.Hour}, metadata, writer)
+
+	sensor := &Sensor{ID: "sensor-1", AccountID: "account-1"}
+	readingTimestamp := time.Unix(1000, 0)
+	msg := &SensorReadingQueueMessage{RelayID: "relay-1", Measurements: []Measurement{{Name: "temperature", Value: 20}}}
+	if err := store.RecordMeasurement(context.Background(), msg, sensor, &readingTimestamp); err != nil {
+		t.Fatalf("RecordMeasurement returned error: %v", err)
+	}
+
+	if writer.pointCount() != 0 {
+		t.Fatalf("expected the point to still be buffered before Close, got %d written", writer.pointCount())
+	}
+
+	if err := store.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if writer.pointCount() != 1 {
+		t.Fatalf("expected Close to flush the buffered point, got %d written", writer.pointCount())
+	}
+}